@@ -0,0 +1,63 @@
+// Package audit records who filed, approved, rejected, or otherwise acted
+// on a request, independent of whatever the underlying Vault audit device
+// happens to capture on cubbyhole writes.
+package audit
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventType identifies a point in a request's lifecycle.
+type EventType string
+
+const (
+	EventCreate        EventType = "create"
+	EventApprove       EventType = "approve"
+	EventReject        EventType = "reject"
+	EventRootGenerated EventType = "root_generated"
+	EventExpired       EventType = "expired"
+)
+
+// Event records a single lifecycle action taken on a request.
+type Event struct {
+	Type EventType
+	Hash string
+
+	// Sequence is monotonic per Hash, so a reader can detect a gap (a
+	// missing or reordered event) in the trail.
+	Sequence int
+
+	// EntityAccessor and EntityName identify the authenticated caller,
+	// pulled from vault.AuthInfo. Empty for events with no caller, such
+	// as an expiry detected by the Reaper.
+	EntityAccessor string
+	EntityName     string
+
+	// UnsealProgress is the number of unseal keys gathered so far,
+	// populated only for EventApprove.
+	UnsealProgress int
+
+	// Nonce identifies the generate-root attempt, populated only for
+	// EventRootGenerated.
+	Nonce string
+
+	// ContentSHA256 is the hash of the request's contents at the time of
+	// the event, so a reader can detect the cubbyhole record having been
+	// tampered with after the fact.
+	ContentSHA256 string
+}
+
+// Auditor emits a lifecycle Event to some durable sink.
+type Auditor interface {
+	Emit(event Event) error
+}
+
+// nextSequence returns the next sequence number to stamp for hash in
+// counters, for an Auditor implementation (FileAuditor, SyslogAuditor)
+// whose backing sink has no way to derive one the way CubbyholeAuditor
+// does by counting its own durable log.
+func nextSequence(counters *sync.Map, hash string) int {
+	counter, _ := counters.LoadOrStore(hash, new(int64))
+	return int(atomic.AddInt64(counter.(*int64), 1))
+}
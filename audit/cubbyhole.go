@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/hashicorp/vault/api"
+)
+
+// CubbyholeAuditor appends each event as a JSON line under
+// audit/requests/<hash>, so a request's trail lives right next to the
+// request itself and inherits the same access controls.
+type CubbyholeAuditor struct{}
+
+func (CubbyholeAuditor) Emit(event Event) error {
+	path := "audit/requests/" + event.Hash
+
+	existing := ""
+	var resp *api.Secret
+	if err := vault.WithRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole(path)
+		return err
+	}); err != nil {
+		// without the existing log, writing would restart the sequence at
+		// 1 and overwrite the trail
+		return err
+	}
+	if resp != nil {
+		if raw, ok := resp.Data["log"].(string); ok {
+			existing = raw
+		}
+	}
+
+	// Sequence is derived from the durable log itself, not an in-process
+	// counter, so it stays monotonic per hash across goldfish replicas
+	// and process restarts rather than only within a single process.
+	event.Sequence = 1
+	if existing != "" {
+		event.Sequence = len(strings.Split(existing, "\n")) + 1
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	log := string(line)
+	if existing != "" {
+		log = existing + "\n" + log
+	}
+
+	return vault.WithRetry(func() error {
+		_, err := vault.WriteToCubbyhole(path, map[string]interface{}{
+			"log": log,
+		})
+		return err
+	})
+}
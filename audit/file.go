@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+)
+
+// FileAuditor appends each event as a JSON line to an operator-configured
+// file, e.g. for shipping to a log aggregator alongside goldfish's other
+// operational logs.
+type FileAuditor struct {
+	out io.Writer
+
+	// sequences tracks the next sequence number to stamp per hash. A
+	// local file has no durable, cross-replica store the way cubbyhole
+	// does, so - like the file itself - this is only monotonic within
+	// this process's lifetime, not across replicas or restarts.
+	sequences sync.Map
+}
+
+func NewFileAuditor(path string) (*FileAuditor, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditor{out: f}, nil
+}
+
+func (a *FileAuditor) Emit(event Event) error {
+	event.Sequence = nextSequence(&a.sequences, event.Hash)
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = a.out.Write(append(line, '\n'))
+	return err
+}
+
+// SyslogAuditor writes each event as a JSON payload to the local syslog
+// daemon, for operators who already centralize logs that way.
+type SyslogAuditor struct {
+	writer *syslog.Writer
+
+	// see FileAuditor.sequences: only monotonic within this process
+	sequences sync.Map
+}
+
+func NewSyslogAuditor() (*SyslogAuditor, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "goldfish")
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogAuditor{writer: w}, nil
+}
+
+func (a *SyslogAuditor) Emit(event Event) error {
+	event.Sequence = nextSequence(&a.sequences, event.Hash)
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return a.writer.Info(string(line))
+}
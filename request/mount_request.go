@@ -0,0 +1,115 @@
+package request
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/mitchellh/hashstructure"
+)
+
+func init() {
+	Register("mount", func() Request { return &MountRequest{} })
+}
+
+// MountRequest gates enabling a new mount or tuning an existing one's
+// configuration behind the same N-of-M unseal approval flow as a root
+// token generation, since goldfish must hold a root (or sudo) token to
+// perform either operation.
+type MountRequest struct {
+	Type string
+
+	RequestedBy   string
+	RequestedTime int64
+
+	// path of the mount to enable or tune, e.g. "secret/" or "aws/"
+	MountPath string
+	// mount type, e.g. "kv", "aws", "pki"; empty when only tuning
+	MountType string
+	// raw mount_tune style config, e.g. {"default_lease_ttl": "1h"}
+	Config map[string]interface{}
+
+	RequiredUnsealKeys int
+
+	CreatedAt int64
+	TTL       time.Duration
+}
+
+func (m *MountRequest) IsRootOnly() bool {
+	return true
+}
+
+func (m *MountRequest) GetCreatedAt() int64 {
+	return m.CreatedAt
+}
+
+func (m *MountRequest) GetTTL() time.Duration {
+	return m.TTL
+}
+
+func (m *MountRequest) Verify(auth *vault.AuthInfo) error {
+	if m.MountPath == "" {
+		return errors.New("Mount path cannot be empty")
+	}
+	return nil
+}
+
+func (m *MountRequest) Create(auth *vault.AuthInfo, raw map[string]interface{}) (string, error) {
+	path, ok := raw["MountPath"].(string)
+	if !ok || path == "" {
+		return "", errors.New("MountPath must be provided")
+	}
+	m.Type = "Mount"
+	m.MountPath = path
+	if mountType, ok := raw["MountType"].(string); ok {
+		m.MountType = mountType
+	}
+	if config, ok := raw["Config"].(map[string]interface{}); ok {
+		m.Config = config
+	}
+	if auth != nil {
+		m.RequestedBy = auth.DisplayName
+	}
+	m.RequestedTime = time.Now().Unix()
+	m.RequiredUnsealKeys = requiredUnsealKeys(raw, 3)
+	m.CreatedAt = time.Now().Unix()
+	m.TTL = DefaultRequestTTL
+
+	hash, err := hashstructure.Hash(m, nil)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(hash, 16), nil
+}
+
+// Approve collects an unseal key and, once enough have been gathered,
+// generates a short-lived root token and applies the mount change with it.
+func (m *MountRequest) Approve(hash string, unseal string) error {
+	if unseal == "" {
+		return errors.New("Unseal key cannot be empty")
+	}
+
+	unseals, ready, err := collectUnseal(hash, unseal, m.RequiredUnsealKeys)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return nil
+	}
+
+	token, err := generateRootToken(hash, unseals)
+	if err != nil {
+		return err
+	}
+
+	if err := vault.TuneMount(token, m.MountPath, m.MountType, m.Config); err != nil {
+		return err
+	}
+
+	return completeRequest(hash)
+}
+
+func (m *MountRequest) Reject(auth *vault.AuthInfo, hash string) error {
+	return rejectRequest(hash)
+}
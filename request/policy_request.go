@@ -0,0 +1,139 @@
+package request
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/mitchellh/hashstructure"
+)
+
+func init() {
+	Register("policy", func() Request { return &PolicyRequest{} })
+}
+
+// PolicyRequest gates writing an ACL policy behind the N-of-M unseal
+// approval flow, since writing sys/policy requires a root (or sudo)
+// token goldfish does not otherwise hold.
+type PolicyRequest struct {
+	Type string
+
+	RequestedBy   string
+	RequestedTime int64
+
+	PolicyName  string
+	PolicyRules string
+
+	// PGPKey, if provided at creation time, is an ASCII-armored public
+	// key. When set, the final unseal generates the root token via Vault's
+	// PGP path instead of the plaintext OTP path: goldfish never holds it
+	// decrypted, so it does NOT write the policy itself in this case. The
+	// encrypted token and its fingerprint are recorded under
+	// completed/<hash> (see completeRequestWithResult) for the requester
+	// to fetch with GetResult - PGPKey trades "goldfish applies the
+	// policy" for "the requester gets a root token of their own to do it
+	// themselves".
+	PGPKey string
+
+	RequiredUnsealKeys int
+
+	CreatedAt int64
+	TTL       time.Duration
+}
+
+func (p *PolicyRequest) IsRootOnly() bool {
+	return true
+}
+
+func (p *PolicyRequest) GetCreatedAt() int64 {
+	return p.CreatedAt
+}
+
+func (p *PolicyRequest) GetTTL() time.Duration {
+	return p.TTL
+}
+
+func (p *PolicyRequest) Verify(auth *vault.AuthInfo) error {
+	if p.PolicyName == "" {
+		return errors.New("PolicyName cannot be empty")
+	}
+	return nil
+}
+
+func (p *PolicyRequest) Create(auth *vault.AuthInfo, raw map[string]interface{}) (string, error) {
+	name, ok := raw["PolicyName"].(string)
+	if !ok || name == "" {
+		return "", errors.New("PolicyName must be provided")
+	}
+	rules, ok := raw["PolicyRules"].(string)
+	if !ok || rules == "" {
+		return "", errors.New("PolicyRules must be provided")
+	}
+
+	p.Type = "Policy"
+	p.PolicyName = name
+	p.PolicyRules = rules
+	if pgpKey, ok := raw["PGPKey"].(string); ok {
+		p.PGPKey = pgpKey
+	}
+	if auth != nil {
+		p.RequestedBy = auth.DisplayName
+	}
+	p.RequestedTime = time.Now().Unix()
+	p.RequiredUnsealKeys = requiredUnsealKeys(raw, 3)
+	p.CreatedAt = time.Now().Unix()
+	p.TTL = DefaultRequestTTL
+
+	hash, err := hashstructure.Hash(p, nil)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(hash, 16), nil
+}
+
+// Approve collects an unseal key and, once enough have been gathered,
+// either writes the policy using a short-lived plaintext root token, or,
+// if PGPKey was supplied at creation time, generates the root token via
+// Vault's PGP path instead and hands the still-encrypted result back to
+// the requester via GetResult - goldfish never holds that token in the
+// clear, so it cannot use it to write the policy itself in that case.
+func (p *PolicyRequest) Approve(hash string, unseal string) error {
+	if unseal == "" {
+		return errors.New("Unseal key cannot be empty")
+	}
+
+	unseals, ready, err := collectUnseal(hash, unseal, p.RequiredUnsealKeys)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return nil
+	}
+
+	if p.PGPKey != "" {
+		encryptedToken, fingerprint, err := generateRootTokenPGP(hash, unseals, p.PGPKey)
+		if err != nil {
+			return err
+		}
+		return completeRequestWithResult(hash, map[string]interface{}{
+			"EncryptedRootToken": encryptedToken,
+			"TokenFingerprint":   fingerprint,
+		})
+	}
+
+	token, err := generateRootToken(hash, unseals)
+	if err != nil {
+		return err
+	}
+
+	if err := vault.WritePolicy(token, p.PolicyName, p.PolicyRules); err != nil {
+		return err
+	}
+
+	return completeRequest(hash)
+}
+
+func (p *PolicyRequest) Reject(auth *vault.AuthInfo, hash string) error {
+	return rejectRequest(hash)
+}
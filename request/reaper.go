@@ -0,0 +1,130 @@
+package request
+
+import (
+	"context"
+	"time"
+
+	"github.com/caiyeon/goldfish/audit"
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/hashicorp/vault/api"
+)
+
+// reapInterval is how often the Reaper sweeps requests/ for expired entries.
+const reapInterval = time.Minute
+
+// Init starts the background Reaper. It is meant to be called once, from
+// goldfish's startup path, and stops when ctx is cancelled.
+func Init(ctx context.Context) {
+	go reap(ctx)
+}
+
+func reap(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweep()
+			sweepCompleted()
+		}
+	}
+}
+
+// sweep lists every outstanding request and deletes the ones whose TTL
+// has lapsed, along with their companion unseal wrapping tokens.
+func sweep() {
+	var hashes []string
+	err := withRetry(func() (err error) {
+		hashes, err = vault.ListCubbyhole("requests")
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	for _, hash := range hashes {
+		var resp *api.Secret
+		err := withRetry(func() (err error) {
+			resp, err = vault.ReadFromCubbyhole("requests/" + hash)
+			return err
+		})
+		if err != nil || resp == nil {
+			continue
+		}
+
+		req, err := decode(resp.Data, "")
+		if err != nil {
+			continue
+		}
+		if !isExpired(req) {
+			continue
+		}
+
+		release, err := acquireHashLock(hash)
+		if err != nil {
+			// someone else is actively working this hash; it'll be
+			// picked up on the next sweep if it is still expired then
+			continue
+		}
+
+		withRetry(func() error {
+			_, err := vault.DeleteFromCubbyhole("requests/" + hash)
+			return err
+		})
+		withRetry(func() error {
+			_, err := vault.DeleteFromCubbyhole("unseal_wrapping_tokens/" + hash)
+			return err
+		})
+
+		emitAudit(audit.EventExpired, hash, nil, nil, nil)
+		release()
+	}
+}
+
+// sweepCompleted lists every recorded completion result and deletes the
+// ones older than completedResultTTL, so an unclaimed issued-accessor or
+// PGP-encrypted token doesn't sit in cubbyhole indefinitely.
+func sweepCompleted() {
+	var hashes []string
+	err := withRetry(func() (err error) {
+		hashes, err = vault.ListCubbyhole("completed")
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	for _, hash := range hashes {
+		var resp *api.Secret
+		err := withRetry(func() (err error) {
+			resp, err = vault.ReadFromCubbyhole("completed/" + hash)
+			return err
+		})
+		if err != nil || resp == nil {
+			continue
+		}
+
+		completedAt, ok := resp.Data[completedAtKey].(int64)
+		if !ok {
+			if f, ok := resp.Data[completedAtKey].(float64); ok {
+				completedAt = int64(f)
+			}
+		}
+		if time.Now().Unix() < completedAt+int64(completedResultTTL.Seconds()) {
+			continue
+		}
+
+		release, err := acquireHashLock(hash)
+		if err != nil {
+			continue
+		}
+		withRetry(func() error {
+			_, err := vault.DeleteFromCubbyhole("completed/" + hash)
+			return err
+		})
+		release()
+	}
+}
@@ -0,0 +1,58 @@
+// +build integration
+
+package request
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/fatih/structs"
+)
+
+// TestSweepPurgesRequestAndUnsealTokensTogether exercises sweep() against
+// a real Vault: a request whose TTL has already lapsed, plus a companion
+// unseal_wrapping_tokens entry left behind by a partial approval, must
+// both disappear from the same sweep() pass - there's no clock to fake
+// here (sweep() only ever compares against time.Now()), so the request
+// is seeded with a CreatedAt already in the past instead. Needs a live
+// Vault, so it is gated behind the "integration" build tag and an
+// explicit VAULT_ADDR:
+//
+//	VAULT_ADDR=... VAULT_TOKEN=... go test -tags=integration ./request/... -run TestSweepPurgesRequestAndUnsealTokensTogether
+func TestSweepPurgesRequestAndUnsealTokensTogether(t *testing.T) {
+	if os.Getenv("VAULT_ADDR") == "" {
+		t.Skip("set VAULT_ADDR (and VAULT_TOKEN) to run against a dev Vault")
+	}
+
+	hash := "integration-test-reaper-hash"
+	req := &MountRequest{
+		Type:      "Mount",
+		MountPath: "integration-test/",
+		CreatedAt: time.Now().Add(-2 * DefaultRequestTTL).Unix(),
+		TTL:       DefaultRequestTTL,
+	}
+
+	if _, err := vault.WriteToCubbyhole("requests/"+hash, structs.Map(req)); err != nil {
+		t.Fatalf("seed request: %v", err)
+	}
+	if _, err := vault.WriteToCubbyhole("unseal_wrapping_tokens/"+hash, map[string]interface{}{
+		"wrapping_tokens": "dummy-wrapping-token",
+	}); err != nil {
+		t.Fatalf("seed unseal tokens: %v", err)
+	}
+
+	sweep()
+
+	if resp, err := vault.ReadFromCubbyhole("requests/" + hash); err != nil {
+		t.Fatalf("read back request: %v", err)
+	} else if resp != nil {
+		t.Error("expired request should have been purged by sweep()")
+	}
+	if resp, err := vault.ReadFromCubbyhole("unseal_wrapping_tokens/" + hash); err != nil {
+		t.Fatalf("read back unseal tokens: %v", err)
+	} else if resp != nil {
+		t.Error("companion unseal wrapping tokens should have been purged alongside the request")
+	}
+}
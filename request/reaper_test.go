@@ -0,0 +1,44 @@
+package request
+
+import (
+	"testing"
+	"time"
+)
+
+// sweep() itself talks directly to vault.ListCubbyhole/ReadFromCubbyhole/
+// DeleteFromCubbyhole with no injected client, so "both the request and
+// its wrapped unseal tokens are purged together" is exercised end to end
+// by TestSweepPurgesRequestAndUnsealTokensTogether in
+// reaper_integration_test.go instead, which needs a live Vault and so
+// runs behind the "integration" build tag. What's tested here, without
+// one, is the expiry check sweep() gates on: isExpired must flip from
+// false to true exactly when a request's CreatedAt+TTL has lapsed. It is
+// checked with a MountRequest; isExpired reads only GetCreatedAt and
+// GetTTL from the Request interface.
+func TestIsExpired(t *testing.T) {
+	now := time.Now().Unix()
+
+	notYetExpired := &MountRequest{
+		CreatedAt: now,
+		TTL:       time.Hour,
+	}
+	if isExpired(notYetExpired) {
+		t.Error("request created just now with a 1h TTL should not be expired")
+	}
+
+	alreadyExpired := &MountRequest{
+		CreatedAt: now - int64(2*time.Hour/time.Second),
+		TTL:       time.Hour,
+	}
+	if !isExpired(alreadyExpired) {
+		t.Error("request created 2h ago with a 1h TTL should be expired")
+	}
+
+	rightAtTheBoundary := &MountRequest{
+		CreatedAt: now - int64(DefaultRequestTTL/time.Second),
+		TTL:       DefaultRequestTTL,
+	}
+	if !isExpired(rightAtTheBoundary) {
+		t.Error("request whose TTL lapsed exactly now should be expired")
+	}
+}
@@ -0,0 +1,54 @@
+package request
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/hashstructure"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Registry maps a lowercased request type name to a factory that produces
+// a zero-value Request of that concrete type. Concrete request types
+// register themselves here from their own init(), so Add/Get/Approve/Reject
+// never need a per-type switch statement.
+var Registry = map[string]func() Request{}
+
+// Register associates typeName (case-insensitively) with a factory.
+// It is meant to be called once, from a concrete request type's init().
+func Register(typeName string, factory func() Request) {
+	Registry[strings.ToLower(typeName)] = factory
+}
+
+// decode looks up the factory registered for raw["Type"], decodes raw into
+// a fresh instance via mapstructure, and (if hash is non-empty) verifies
+// that the instance still hashes to the hash it was stored under.
+func decode(raw map[string]interface{}, hash string) (Request, error) {
+	t := ""
+	if typeRaw, ok := raw["Type"]; ok {
+		t, _ = typeRaw.(string)
+	}
+	if t == "" {
+		return nil, errors.New("Invalid request type")
+	}
+
+	factory, ok := Registry[strings.ToLower(t)]
+	if !ok {
+		return nil, errors.New("Unsupported request type: " + t)
+	}
+	req := factory()
+
+	if err := mapstructure.Decode(raw, req); err != nil {
+		return nil, err
+	}
+
+	if hash != "" {
+		hash_uint64, err := hashstructure.Hash(req, nil)
+		if err != nil || strconv.FormatUint(hash_uint64, 16) != hash {
+			return nil, errors.New("Hashes do not match")
+		}
+	}
+
+	return req, nil
+}
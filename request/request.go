@@ -1,30 +1,108 @@
 package request
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
-	"sync"
+	"time"
 
+	"github.com/caiyeon/goldfish/audit"
 	"github.com/caiyeon/goldfish/vault"
 	"github.com/gorilla/securecookie"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/xor"
-	"github.com/mitchellh/hashstructure"
-	"github.com/mitchellh/mapstructure"
 	"github.com/fatih/structs"
 
 	"golang.org/x/sync/syncmap"
 )
 
-// operations on the same request should not interweave,
-// a map of string to string (hash) will prevent this race condition
+// operations on the same request should not interweave. This is the
+// fallback lock used when Vault has no KV v2 mount to back acquireHashLock
+// with a cluster-safe CAS lock; it only coordinates goroutines inside a
+// single goldfish process.
 var lockMap syncmap.Map
 
-// only one goroutine should perform vault root generation at a time
-var lockRoot sync.Mutex
+// rootGenerationLockKey is the well-known acquireHashLock key that
+// serializes generateRootToken/generateRootTokenPGP: Vault supports only
+// one generate-root attempt at a time cluster-wide, so this must be a
+// Vault-backed lock rather than a process-local mutex, the same reason
+// request hashes themselves are locked through acquireHashLock instead
+// of a plain in-process map.
+const rootGenerationLockKey = "root-generation"
+
+// requests are locked for this long at a time; held locks are refreshed
+// well before this lapses (see lockRefreshInterval) so a long-running
+// operation such as generateRootToken is not forced to complete within it
+const lockTTL = 30 * time.Second
+
+// lockRefreshInterval is how often a held Vault-backed lock is renewed in
+// the background, comfortably inside lockTTL so a slow Approve (several
+// sequential Vault round-trips, each retried with backoff) doesn't let
+// the lock lapse and get raced by another replica mid-operation.
+const lockRefreshInterval = lockTTL / 3
+
+// acquireHashLock takes a lock on hash so that Add/Get/Approve/Reject
+// never interleave on the same request, even across goldfish replicas
+// behind a load balancer: it prefers a Vault-backed CAS lock, which is
+// visible to every replica, and falls back to the in-process lockMap
+// only when Vault has no KV v2 mount to store it in. A Vault-backed lock
+// is kept alive by a background refresh for as long as it is held, so it
+// does not expire out from under a caller still mid-operation.
+func acquireHashLock(hash string) (release func(), err error) {
+	holderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := vault.AcquireLock(hash, holderID, lockTTL)
+	if err == vault.ErrKVv2Unavailable {
+		if _, loaded := lockMap.LoadOrStore(hash, true); loaded {
+			return nil, errors.New("Someone else is currently editing this request")
+		}
+		return func() { lockMap.Delete(hash) }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, errors.New("Someone else is currently editing this request")
+	}
+
+	stop := make(chan struct{})
+	go refreshLock(hash, holderID, stop)
+	return func() {
+		close(stop)
+		vault.ReleaseLock(hash, holderID)
+	}, nil
+}
+
+// refreshLock re-acquires hash (as the same holderID, which AcquireLock
+// treats as a renewal rather than contention) every lockRefreshInterval
+// until stop is closed, so a lock held across a slow operation does not
+// lapse and get raced by another replica. A failed renewal is logged for
+// the operator to notice, rather than panicking a goroutine with no
+// caller left to report to - the worst case is the lock expiring early,
+// the same risk as if refreshLock did not exist at all.
+func refreshLock(hash, holderID string, stop <-chan struct{}) {
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := vault.AcquireLock(hash, holderID, lockTTL); err != nil && err != vault.ErrKVv2Unavailable {
+				fmt.Println("Failed to refresh lock for request", hash, ":", err)
+			}
+		}
+	}
+}
 
 type Request interface {
 	IsRootOnly() bool
@@ -32,53 +110,73 @@ type Request interface {
 	Approve(string, string) error
 	Reject(*vault.AuthInfo, string) error
 	Create(*vault.AuthInfo, map[string]interface{}) (string, error)
+
+	// GetCreatedAt and GetTTL let the Reaper (and GetAll, for the
+	// frontend) find and report on expiry without knowing a request's
+	// concrete type.
+	GetCreatedAt() int64
+	GetTTL() time.Duration
 }
 
+// DefaultRequestTTL is how long a request lives in cubbyhole before the
+// Reaper purges it, for concrete types that don't need a different value.
+const DefaultRequestTTL = 7 * 24 * time.Hour
+
 // adds a request if user has authentication
 func Add(auth *vault.AuthInfo, raw map[string]interface{}) (string, error) {
 	t := ""
 	if typeRaw, ok := raw["Type"]; ok {
-		t, ok = typeRaw.(string)
+		t, _ = typeRaw.(string)
 	}
 	if t == "" {
 		return "", errors.New("Type field is empty")
 	}
 
-	switch strings.ToLower(t) {
-	case "policy":
-		var req PolicyRequest
-
-		// construct request fields
-		hash, err := req.Create(auth, raw)
-		if err != nil {
-			return "", err
-		}
+	factory, ok := Registry[strings.ToLower(t)]
+	if !ok {
+		return "", errors.New("Unsupported request type")
+	}
+	req := factory()
 
-		// lock hash in map before writing to vault cubbyhole
-		_, loaded := lockMap.LoadOrStore(hash, true)
-		if loaded {
-			return "", errors.New("Someone else is currently editing this request")
-		}
-		defer lockMap.Delete(hash)
+	// construct request fields
+	hash, err := req.Create(auth, raw)
+	if err != nil {
+		return "", err
+	}
 
-		_, err = vault.WriteToCubbyhole("requests/" + hash, structs.Map(req))
-		return hash, err
+	// lock hash before writing to vault cubbyhole
+	release, err := acquireHashLock(hash)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
-	default:
-		return "", errors.New("Unsupported request type")
+	err = withRetry(func() error {
+		_, err := vault.WriteToCubbyhole("requests/"+hash, structs.Map(req))
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
+
+	emitAudit(audit.EventCreate, hash, auth, structs.Map(req), nil)
+	return hash, nil
 }
 
 // fetches a request if it exists, and if user has authentication
 func Get(auth *vault.AuthInfo, hash string) (Request, error) {
-	_, loaded := lockMap.LoadOrStore(hash, true)
-	if loaded {
-		return nil, errors.New("Someone else is currently editing this request")
+	release, err := acquireHashLock(hash)
+	if err != nil {
+		return nil, err
 	}
-	defer lockMap.Delete(hash)
+	defer release()
 
 	// fetch request from cubbyhole
-	resp, err := vault.ReadFromCubbyhole("requests/" + hash)
+	var resp *api.Secret
+	err = withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("requests/" + hash)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -86,49 +184,68 @@ func Get(auth *vault.AuthInfo, hash string) (Request, error) {
 		return nil, errors.New("Change ID not found")
 	}
 
-	// decode secret to a request
-	t := ""
-	if raw, ok := resp.Data["Type"]; ok {
-		t, ok = raw.(string)
+	req, err := decode(resp.Data, hash)
+	if err != nil {
+		return nil, err
 	}
-	if t == "" {
-		return nil, errors.New("Invalid request type")
+	// verify request is still valid
+	if err := req.Verify(auth); err != nil {
+		return nil, err
 	}
+	return req, nil
+}
 
-	switch strings.ToLower(t) {
-	case "policy":
-		// decode secret into policy request
-		var req PolicyRequest
-		if err := mapstructure.Decode(resp.Data, &req); err != nil {
-			return nil, err
+// GetAll fetches every outstanding request, so the frontend can list them
+// (and their remaining TTL) without an approver needing a hash in hand.
+// Requests the caller can no longer Verify are skipped rather than
+// failing the whole call.
+func GetAll(auth *vault.AuthInfo) ([]Request, error) {
+	var hashes []string
+	err := withRetry(func() (err error) {
+		hashes, err = vault.ListCubbyhole("requests")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []Request
+	for _, hash := range hashes {
+		var resp *api.Secret
+		err := withRetry(func() (err error) {
+			resp, err = vault.ReadFromCubbyhole("requests/" + hash)
+			return err
+		})
+		if err != nil || resp == nil {
+			continue
 		}
-		// verify hash
-		hash_uint64, err := hashstructure.Hash(req, nil)
-		if err != nil || strconv.FormatUint(hash_uint64, 16) != hash {
-			return nil, errors.New("Hashes do not match")
+		req, err := decode(resp.Data, hash)
+		if err != nil {
+			continue
 		}
-		// verify policy request is still valid
 		if err := req.Verify(auth); err != nil {
-			return nil, err
+			continue
 		}
-		return &req, nil
-
-	default:
-		return nil, errors.New("Invalid request type: " + t)
+		requests = append(requests, req)
 	}
+	return requests, nil
 }
 
 // if unseal is nonempty string, approve request with current auth
 // otherwise, add unseal to list of unseals to generate root token later
 func Approve(auth *vault.AuthInfo, hash string, unseal string) error {
-	_, loaded := lockMap.LoadOrStore(hash, true)
-	if loaded {
-		return errors.New("Someone else is currently editing this request")
+	release, err := acquireHashLock(hash)
+	if err != nil {
+		return err
 	}
-	defer lockMap.Delete(hash)
+	defer release()
 
 	// fetch request from cubbyhole
-	resp, err := vault.ReadFromCubbyhole("requests/" + hash)
+	var resp *api.Secret
+	err = withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("requests/" + hash)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -136,48 +253,96 @@ func Approve(auth *vault.AuthInfo, hash string, unseal string) error {
 		return errors.New("Change ID not found")
 	}
 
-	// decode secret to a request
-	t := ""
-	if raw, ok := resp.Data["Type"]; ok {
-		t, ok = raw.(string)
+	req, err := decode(resp.Data, hash)
+	if err != nil {
+		return err
 	}
-	if t == "" {
-		return errors.New("Invalid request type")
+	if isExpired(req) {
+		return ErrRequestExpired
+	}
+	// verify request is still valid
+	if err := req.Verify(auth); err != nil {
+		return err
 	}
 
-	switch strings.ToLower(t) {
-	case "policy":
-		// decode secret into policy request
-		var req PolicyRequest
-		if err := mapstructure.Decode(resp.Data, &req); err != nil {
-			return err
-		}
-		// verify hash
-		hash_uint64, err := hashstructure.Hash(req, nil)
-		if err != nil || strconv.FormatUint(hash_uint64, 16) != hash {
-			return errors.New("Hashes do not match")
-		}
-		// verify policy request is still valid
-		if err := req.Verify(auth); err != nil {
-			return err
+	// both captured before req.Approve runs, since a completing Approve
+	// call deletes unseal_wrapping_tokens/<hash> as part of
+	// completeRequest - after that, counting what's left in cubbyhole
+	// would read back 0
+	alreadyPending := hasPendingUnseals(hash)
+	priorProgress := countUnsealProgress(hash)
+
+	if err := req.Approve(hash, unseal); err != nil {
+		return err
+	}
+
+	// collectUnseal only consumes unseal into a fresh wrapping token when
+	// the set isn't already complete; if it was already complete and
+	// persisted as plaintext (alreadyPending), this call merely retried a
+	// stuck root generation, so priorProgress - itself already the full
+	// count - must not be inflated by another +1
+	progress := priorProgress
+	if !alreadyPending {
+		progress++
+	}
+	emitAudit(audit.EventApprove, hash, auth, resp.Data, func(e *audit.Event) {
+		e.UnsealProgress = progress
+	})
+	return nil
+}
+
+// countUnsealProgress returns how many unseal keys have been gathered so
+// far for hash, whether they are still sitting as wrapping tokens or have
+// already been unwrapped to plaintext by collectUnseal.
+func countUnsealProgress(hash string) int {
+	var resp *api.Secret
+	err := withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("unseal_wrapping_tokens/" + hash)
+		return err
+	})
+	if err != nil || resp == nil {
+		return 0
+	}
+	for _, key := range []string{"wrapping_tokens", unsealedTokensKey} {
+		if raw, ok := resp.Data[key].(string); ok && raw != "" {
+			return len(strings.Split(raw, ";"))
 		}
-		return req.Approve(hash, unseal)
+	}
+	return 0
+}
 
-	default:
-		return errors.New("Invalid request type: " + t)
+// hasPendingUnseals reports whether hash's unseal_wrapping_tokens entry
+// has already been fully gathered and unwrapped to plaintext by a prior
+// collectUnseal call - i.e. this Approve call, if it succeeds, is only
+// retrying a stuck root generation rather than consuming unseal as a
+// fresh key.
+func hasPendingUnseals(hash string) bool {
+	var resp *api.Secret
+	err := withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("unseal_wrapping_tokens/" + hash)
+		return err
+	})
+	if err != nil || resp == nil {
+		return false
 	}
+	raw, ok := resp.Data[unsealedTokensKey].(string)
+	return ok && raw != ""
 }
 
 // deletes request, if user is authorized to read resource
 func Reject(auth *vault.AuthInfo, hash string) error {
-	_, loaded := lockMap.LoadOrStore(hash, true)
-	if loaded {
-		return errors.New("Someone else is currently editing this request")
+	release, err := acquireHashLock(hash)
+	if err != nil {
+		return err
 	}
-	defer lockMap.Delete(hash)
+	defer release()
 
 	// fetch request from cubbyhole
-	resp, err := vault.ReadFromCubbyhole("requests/" + hash)
+	var resp *api.Secret
+	err = withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("requests/" + hash)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -185,34 +350,17 @@ func Reject(auth *vault.AuthInfo, hash string) error {
 		return errors.New("Change ID not found")
 	}
 
-	// decode secret to a request
-	t := ""
-	if raw, ok := resp.Data["Type"]; ok {
-		t, ok = raw.(string)
-	}
-	if t == "" {
-		return errors.New("Invalid request type")
+	req, err := decode(resp.Data, hash)
+	if err != nil {
+		return err
 	}
 
-	// verify user can access resource
-	switch strings.ToLower(t) {
-	case "policy":
-		// decode secret into policy request
-		var req PolicyRequest
-		if err := mapstructure.Decode(resp.Data, &req); err != nil {
-			return err
-		}
-		// verify hash
-		hash_uint64, err := hashstructure.Hash(req, nil)
-		if err != nil || strconv.FormatUint(hash_uint64, 16) != hash {
-			return errors.New("Hashes do not match")
-		}
-		// verify policy request is still valid
-		return req.Reject(auth, hash)
-
-	default:
-		return errors.New("Invalid request type: " + t)
+	if err := req.Reject(auth, hash); err != nil {
+		return err
 	}
+
+	emitAudit(audit.EventReject, hash, auth, resp.Data, nil)
+	return nil
 }
 
 func IsRootOnly(req Request) bool {
@@ -221,25 +369,43 @@ func IsRootOnly(req Request) bool {
 
 // attempts to generate a root token via unseal keys
 // will return error if another key generation process is underway
-func generateRootToken(unsealKeys []string) (string, error) {
-	lockRoot.Lock()
-	defer lockRoot.Unlock()
+func generateRootToken(hash string, unsealKeys []string) (string, error) {
+	release, err := acquireHashLock(rootGenerationLockKey)
+	if err != nil {
+		return "", err
+	}
+	defer release()
 
 	otp := base64.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(16))
-	status, err := vault.GenerateRootInit(otp)
+	var status *vault.RootGenerationStatus
+	err = withRetry(func() (err error) {
+		status, err = vault.GenerateRootInit(otp, "")
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 
 	if status.EncodedRootToken == "" {
 		for _, s := range unsealKeys {
-			status, err = vault.GenerateRootUpdate(s, status.Nonce)
-			// an error likely means one of the unseals was not valid
+			err = withRetry(func() (err error) {
+				status, err = vault.GenerateRootUpdate(s, status.Nonce)
+				return err
+			})
 			if err != nil {
+				// a recoverable (transient) failure should not force the
+				// unseal keys already consumed to be discarded; they stay
+				// persisted by collectUnseal, and the attempt is cancelled
+				// so the next approval can start a fresh one with them
+				if vault.Recoverable(err) {
+					vault.GenerateRootCancel()
+					return "", err
+				}
+				// anything else likely means one of the unseals was not
+				// valid, so the whole attempt is unrecoverable
 				errS := "Could not generate root token: " + err.Error()
-				// try to cancel the root generation
-				if err := vault.GenerateRootCancel(); err != nil {
-					errS += ". Attempted to cancel root generation, but: " + err.Error()
+				if cancelErr := vault.GenerateRootCancel(); cancelErr != nil {
+					errS += ". Attempted to cancel root generation, but: " + cancelErr.Error()
 				}
 				return "", errors.New(errS)
 			}
@@ -260,13 +426,76 @@ func generateRootToken(unsealKeys []string) (string, error) {
 		return "", errors.New("Could not decode root token. Please search and revoke")
 	}
 
+	emitAudit(audit.EventRootGenerated, hash, nil, nil, func(e *audit.Event) {
+		e.Nonce = status.Nonce
+	})
 	return token, nil
 }
 
+// generateRootTokenPGP behaves like generateRootToken, except the root
+// token never passes through goldfish's memory. Vault encrypts it
+// against pgpKey and hands back the armored ciphertext, which only the
+// holder of the matching private key can decrypt.
+func generateRootTokenPGP(hash string, unsealKeys []string, pgpKey string) (encryptedToken string, fingerprint string, err error) {
+	release, err := acquireHashLock(rootGenerationLockKey)
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+
+	var status *vault.RootGenerationStatus
+	err = withRetry(func() (err error) {
+		status, err = vault.GenerateRootInit("", pgpKey)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if status.EncodedRootToken == "" {
+		for _, s := range unsealKeys {
+			err = withRetry(func() (err error) {
+				status, err = vault.GenerateRootUpdate(s, status.Nonce)
+				return err
+			})
+			if err != nil {
+				if vault.Recoverable(err) {
+					// cancelled so the next approval can start a fresh
+					// attempt with the unseal keys collectUnseal persisted
+					vault.GenerateRootCancel()
+					return "", "", err
+				}
+				// anything else likely means one of the unseals was not
+				// valid, so the whole attempt is unrecoverable
+				errS := "Could not generate root token: " + err.Error()
+				if cancelErr := vault.GenerateRootCancel(); cancelErr != nil {
+					errS += ". Attempted to cancel root generation, but: " + cancelErr.Error()
+				}
+				return "", "", errors.New(errS)
+			}
+		}
+	}
+
+	if status.EncodedRootToken == "" {
+		return "", "", errors.New("Could not generate root token. Was vault re-keyed just now?")
+	}
+
+	// EncodedRootToken is now an ASCII-armored PGP ciphertext; goldfish
+	// returns it as-is rather than decrypting, since it holds no private key
+	emitAudit(audit.EventRootGenerated, hash, nil, nil, func(e *audit.Event) {
+		e.Nonce = status.Nonce
+	})
+	return status.EncodedRootToken, status.PGPFingerprint, nil
+}
+
 // writes the provided unseal in and returns a slice of all unseals in hash
 func appendUnseal(hash, unseal string) ([]string, error) {
 	// read current request from cubbyhole
-	resp, err := vault.ReadFromCubbyhole("unseal_wrapping_tokens/" + hash)
+	var resp *api.Secret
+	err := withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("unseal_wrapping_tokens/" + hash)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -286,8 +515,12 @@ func appendUnseal(hash, unseal string) ([]string, error) {
 	}
 
 	// wrap the unseal token
-	newWrappingToken, err := vault.WrapData("60m", map[string]interface{}{
-		"unseal_token": unseal,
+	var newWrappingToken string
+	err = withRetry(func() (err error) {
+		newWrappingToken, err = vault.WrapData("60m", map[string]interface{}{
+			"unseal_token": unseal,
+		})
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -297,25 +530,253 @@ func appendUnseal(hash, unseal string) ([]string, error) {
 	wrappingTokens = append(wrappingTokens, newWrappingToken)
 
 	// write the unseals back to the cubbyhole
-	_, err = vault.WriteToCubbyhole("unseal_wrapping_tokens/"+hash,
-		map[string]interface{}{
-			"wrapping_tokens": strings.Trim(strings.Join(strings.Fields(fmt.Sprint(wrappingTokens)), ";"), "[]"),
-		},
-	)
+	err = withRetry(func() error {
+		_, err := vault.WriteToCubbyhole("unseal_wrapping_tokens/"+hash,
+			map[string]interface{}{
+				"wrapping_tokens": strings.Trim(strings.Join(strings.Fields(fmt.Sprint(wrappingTokens)), ";"), "[]"),
+			},
+		)
+		return err
+	})
 	return wrappingTokens, err
 }
 
+// unsealedTokensKey marks the unseal_wrapping_tokens/<hash> cubbyhole
+// entry as already-unwrapped plaintext. Each response-wrapping token
+// unwrapUnseals consumes is single-use, so there is no way to retry the
+// unwrap itself; collectUnseal persists the plaintext here, in place of
+// the now-useless wrapping tokens, the moment enough have been gathered,
+// so a generateRootToken/generateRootTokenPGP call that then fails with
+// a RecoverableError can be retried via a later Approve(hash, unseal)
+// call without needing a fresh key or re-unwrapping anything.
+const unsealedTokensKey = "unsealed_tokens"
+
+// collectUnseal feeds unseal into hash's collection of response-wrapped
+// unseal tokens and reports whether enough have now been gathered to
+// proceed to root generation (ready). If a previous call already
+// finished gathering and unwrapping them but the caller's subsequent
+// root generation attempt failed recoverably, this resumes from the
+// persisted plaintext instead of consuming unseal at all.
+func collectUnseal(hash, unseal string, required int) (unseals []string, ready bool, err error) {
+	var resp *api.Secret
+	err = withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("unseal_wrapping_tokens/" + hash)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if resp != nil {
+		if raw, ok := resp.Data[unsealedTokensKey].(string); ok && raw != "" {
+			return strings.Split(raw, ";"), true, nil
+		}
+	}
+
+	wrappingTokens, err := appendUnseal(hash, unseal)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(wrappingTokens) < required {
+		return nil, false, nil
+	}
+
+	unseals, err = unwrapUnseals(wrappingTokens)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = withRetry(func() error {
+		_, err := vault.WriteToCubbyhole("unseal_wrapping_tokens/"+hash, map[string]interface{}{
+			unsealedTokensKey: strings.Join(unseals, ";"),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return unseals, true, nil
+}
+
 func unwrapUnseals(wrappingTokens []string) (unseals []string, err error) {
 	for _, wrappingToken := range wrappingTokens {
-		data, err := vault.UnwrapData(wrappingToken)
+		var data map[string]interface{}
+		err := withRetry(func() (err error) {
+			data, err = vault.UnwrapData(wrappingToken)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
-		if unseal, ok := data["unseal_token"]; ok {
-			unseals = append(unseals, unseal.(string))
-		} else {
+		if data == nil {
 			return nil, errors.New("One of the wrapping tokens timed out. Progress reset.")
 		}
+		unsealRaw, ok := data["unseal_token"]
+		if !ok {
+			return nil, errors.New("One of the wrapping tokens timed out. Progress reset.")
+		}
+		unseal, ok := unsealRaw.(string)
+		if !ok || unseal == "" {
+			return nil, errors.New("One of the wrapping tokens timed out. Progress reset.")
+		}
+		unseals = append(unseals, unseal)
 	}
 	return
 }
+
+// withRetry is vault.WithRetry, kept as a package-local alias so every
+// call site in this file stays as short as the rest of the Vault calls
+// it wraps.
+func withRetry(op func() error) error {
+	return vault.WithRetry(op)
+}
+
+// auditor is the sink every lifecycle event is emitted to. It defaults to
+// writing alongside the request itself, but an operator can redirect it
+// to an external sink via SetAuditor.
+var auditor audit.Auditor = audit.CubbyholeAuditor{}
+
+// SetAuditor overrides the default audit sink, e.g. to a FileAuditor or
+// SyslogAuditor configured from goldfish's config file.
+func SetAuditor(a audit.Auditor) {
+	auditor = a
+}
+
+// emitAudit stamps and emits a lifecycle event. content, if non-nil, is
+// hashed so tampering with the cubbyhole record after the fact can be
+// detected by comparing against the digest in the trail. configure, if
+// non-nil, sets event-type-specific fields before emission. Sequence is
+// intentionally left unset here: the auditor itself (the thing actually
+// writing the trail) is what derives it. CubbyholeAuditor derives it from
+// its durable log, so it holds across goldfish replicas and process
+// restarts; FileAuditor and SyslogAuditor keep it only within one process.
+func emitAudit(eventType audit.EventType, hash string, auth *vault.AuthInfo, content interface{}, configure func(*audit.Event)) {
+	event := audit.Event{
+		Type: eventType,
+		Hash: hash,
+	}
+	if auth != nil {
+		event.EntityAccessor = auth.Accessor
+		event.EntityName = auth.DisplayName
+	}
+	if content != nil {
+		if b, err := json.Marshal(content); err == nil {
+			sum := sha256.Sum256(b)
+			event.ContentSHA256 = hex.EncodeToString(sum[:])
+		}
+	}
+	if configure != nil {
+		configure(&event)
+	}
+	// an audit sink failure should not unwind an otherwise successful
+	// request operation; it is logged for the operator to notice
+	if err := auditor.Emit(event); err != nil {
+		fmt.Println("Failed to emit audit event:", err)
+	}
+}
+
+// ErrRequestExpired is returned by Approve when a request's TTL has
+// lapsed, so a caller can distinguish it from an ordinary rejection.
+var ErrRequestExpired = errors.New("This request has expired")
+
+func isExpired(req Request) bool {
+	return time.Now().Unix() >= req.GetCreatedAt()+int64(req.GetTTL().Seconds())
+}
+
+// completeRequest deletes a request and its wrapped unseal tokens once
+// its approval flow has finished executing the underlying Vault mutation.
+// Every concrete Approve implementation must call this (or
+// completeRequestWithResult) on success, so a retried or replayed Approve
+// call finds "Change ID not found" instead of collecting a fresh round of
+// unseals and re-executing the mutation.
+func completeRequest(hash string) error {
+	err := withRetry(func() error {
+		_, err := vault.DeleteFromCubbyhole("unseal_wrapping_tokens/" + hash)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return withRetry(func() error {
+		_, err := vault.DeleteFromCubbyhole("requests/" + hash)
+		return err
+	})
+}
+
+// completedResultTTL bounds how long a completed request's result is kept
+// around in cubbyhole for the requester to retrieve via GetResult.
+const completedResultTTL = 24 * time.Hour
+
+// completedAtKey stamps when a completed result was recorded, so the
+// Reaper can expire it after completedResultTTL the same way it expires
+// a pending request after its own TTL.
+const completedAtKey = "__completed_at"
+
+// completeRequestWithResult behaves like completeRequest, but first
+// persists result under completed/<hash>, so a concrete Approve that
+// produces something the requester needs (an issued token's accessor, a
+// PGP-encrypted root token) has somewhere durable to put it: the request
+// struct itself is about to be deleted, and Approve's signature only
+// returns an error, so nothing else survives this call to hand it back.
+func completeRequestWithResult(hash string, result map[string]interface{}) error {
+	stamped := make(map[string]interface{}, len(result)+1)
+	for k, v := range result {
+		stamped[k] = v
+	}
+	stamped[completedAtKey] = time.Now().Unix()
+
+	err := withRetry(func() error {
+		_, err := vault.WriteToCubbyhole("completed/"+hash, stamped)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return completeRequest(hash)
+}
+
+// GetResult fetches the terminal result of a completed request, e.g. an
+// issued token's accessor or a PGP-encrypted root token, if its Approve
+// recorded one via completeRequestWithResult. Returns (nil, nil) if the
+// request hasn't completed, or completed without recording a result.
+// Knowing the hash is itself the access control here, same as Approve
+// and Reject.
+func GetResult(auth *vault.AuthInfo, hash string) (map[string]interface{}, error) {
+	var resp *api.Secret
+	err := withRetry(func() (err error) {
+		resp, err = vault.ReadFromCubbyhole("completed/" + hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, nil
+	}
+	delete(resp.Data, completedAtKey)
+	return resp.Data, nil
+}
+
+// rejectRequest deletes a pending request and its companion wrapped
+// unseal tokens - the same pair completeRequest deletes, so a request
+// rejected after already collecting at least one key doesn't leave
+// unseal_wrapping_tokens/<hash> behind.
+func rejectRequest(hash string) error {
+	return completeRequest(hash)
+}
+
+// requiredUnsealKeys reads an optional "RequiredUnsealKeys" override from
+// the raw Create payload, falling back to def when it is absent or not a
+// positive number.
+func requiredUnsealKeys(raw map[string]interface{}, def int) int {
+	switch v := raw["RequiredUnsealKeys"].(type) {
+	case float64:
+		if v > 0 {
+			return int(v)
+		}
+	case int:
+		if v > 0 {
+			return v
+		}
+	}
+	return def
+}
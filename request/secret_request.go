@@ -0,0 +1,108 @@
+package request
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/mitchellh/hashstructure"
+)
+
+func init() {
+	Register("secret", func() Request { return &SecretRequest{} })
+}
+
+// SecretRequest gates a write to a sensitive KV path (one goldfish's own
+// service token is deliberately not granted access to) behind the N-of-M
+// unseal approval flow.
+type SecretRequest struct {
+	Type string
+
+	RequestedBy   string
+	RequestedTime int64
+
+	Path string
+	Data map[string]interface{}
+
+	RequiredUnsealKeys int
+
+	CreatedAt int64
+	TTL       time.Duration
+}
+
+func (s *SecretRequest) IsRootOnly() bool {
+	return true
+}
+
+func (s *SecretRequest) GetCreatedAt() int64 {
+	return s.CreatedAt
+}
+
+func (s *SecretRequest) GetTTL() time.Duration {
+	return s.TTL
+}
+
+func (s *SecretRequest) Verify(auth *vault.AuthInfo) error {
+	if s.Path == "" {
+		return errors.New("Path cannot be empty")
+	}
+	return nil
+}
+
+func (s *SecretRequest) Create(auth *vault.AuthInfo, raw map[string]interface{}) (string, error) {
+	path, ok := raw["Path"].(string)
+	if !ok || path == "" {
+		return "", errors.New("Path must be provided")
+	}
+	data, ok := raw["Data"].(map[string]interface{})
+	if !ok || len(data) == 0 {
+		return "", errors.New("Data must be provided")
+	}
+
+	s.Type = "Secret"
+	s.Path = path
+	s.Data = data
+	if auth != nil {
+		s.RequestedBy = auth.DisplayName
+	}
+	s.RequestedTime = time.Now().Unix()
+	s.RequiredUnsealKeys = requiredUnsealKeys(raw, 3)
+	s.CreatedAt = time.Now().Unix()
+	s.TTL = DefaultRequestTTL
+
+	hash, err := hashstructure.Hash(s, nil)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(hash, 16), nil
+}
+
+func (s *SecretRequest) Approve(hash string, unseal string) error {
+	if unseal == "" {
+		return errors.New("Unseal key cannot be empty")
+	}
+
+	unseals, ready, err := collectUnseal(hash, unseal, s.RequiredUnsealKeys)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return nil
+	}
+
+	token, err := generateRootToken(hash, unseals)
+	if err != nil {
+		return err
+	}
+
+	if err := vault.WriteSecret(token, s.Path, s.Data); err != nil {
+		return err
+	}
+
+	return completeRequest(hash)
+}
+
+func (s *SecretRequest) Reject(auth *vault.AuthInfo, hash string) error {
+	return rejectRequest(hash)
+}
@@ -0,0 +1,136 @@
+package request
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/caiyeon/goldfish/vault"
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/hashstructure"
+)
+
+func init() {
+	Register("token", func() Request { return &TokenRequest{} })
+}
+
+// TokenRequest gates creation of an orphan or root-scoped token behind
+// the N-of-M unseal approval flow, since minting such a token requires
+// a root (or sudo) token goldfish does not otherwise hold.
+type TokenRequest struct {
+	Type string
+
+	RequestedBy   string
+	RequestedTime int64
+
+	Policies    []string
+	DisplayName string
+	NumUses     int
+	Orphan      bool
+
+	RequiredUnsealKeys int
+
+	// IssuedAccessor is set once approval completes, and recorded under
+	// completed/<hash> (see completeRequestWithResult) for GetResult to
+	// hand back, since the request itself is deleted at that point.
+	IssuedAccessor string
+
+	CreatedAt int64
+	TTL       time.Duration
+}
+
+func (t *TokenRequest) IsRootOnly() bool {
+	return true
+}
+
+func (t *TokenRequest) GetCreatedAt() int64 {
+	return t.CreatedAt
+}
+
+func (t *TokenRequest) GetTTL() time.Duration {
+	return t.TTL
+}
+
+func (t *TokenRequest) Verify(auth *vault.AuthInfo) error {
+	if len(t.Policies) == 0 {
+		return errors.New("At least one policy must be specified")
+	}
+	return nil
+}
+
+func (t *TokenRequest) Create(auth *vault.AuthInfo, raw map[string]interface{}) (string, error) {
+	policiesRaw, ok := raw["Policies"].([]interface{})
+	if !ok || len(policiesRaw) == 0 {
+		return "", errors.New("Policies must be provided")
+	}
+	for _, p := range policiesRaw {
+		if s, ok := p.(string); ok {
+			t.Policies = append(t.Policies, s)
+		}
+	}
+
+	if displayName, ok := raw["DisplayName"].(string); ok {
+		t.DisplayName = displayName
+	}
+	if orphan, ok := raw["Orphan"].(bool); ok {
+		t.Orphan = orphan
+	}
+	if numUses, ok := raw["NumUses"].(float64); ok {
+		t.NumUses = int(numUses)
+	}
+	t.Type = "Token"
+	if auth != nil {
+		t.RequestedBy = auth.DisplayName
+	}
+	t.RequestedTime = time.Now().Unix()
+	t.RequiredUnsealKeys = requiredUnsealKeys(raw, 3)
+	t.CreatedAt = time.Now().Unix()
+	t.TTL = DefaultRequestTTL
+
+	hash, err := hashstructure.Hash(t, nil)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(hash, 16), nil
+}
+
+func (t *TokenRequest) Approve(hash string, unseal string) error {
+	if unseal == "" {
+		return errors.New("Unseal key cannot be empty")
+	}
+
+	unseals, ready, err := collectUnseal(hash, unseal, t.RequiredUnsealKeys)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return nil
+	}
+
+	rootToken, err := generateRootToken(hash, unseals)
+	if err != nil {
+		return err
+	}
+
+	secret, err := vault.CreateToken(rootToken, &api.TokenCreateRequest{
+		Policies:    t.Policies,
+		DisplayName: t.DisplayName,
+		NumUses:     t.NumUses,
+		NoParent:    t.Orphan,
+	})
+	if err != nil {
+		return err
+	}
+	if secret != nil && secret.Auth != nil {
+		t.IssuedAccessor = secret.Auth.Accessor
+		return completeRequestWithResult(hash, map[string]interface{}{
+			"IssuedAccessor": t.IssuedAccessor,
+		})
+	}
+
+	return completeRequest(hash)
+}
+
+func (t *TokenRequest) Reject(auth *vault.AuthInfo, hash string) error {
+	return rejectRequest(hash)
+}
@@ -0,0 +1,17 @@
+package vault
+
+import (
+	"github.com/hashicorp/vault/api"
+)
+
+// GetLoginClient returns a Vault API client configured from the
+// environment, along with goldfish's own service token. Helpers that
+// need to act as a different identity (e.g. an ephemeral root token from
+// generateRootToken) call client.SetToken afterwards.
+func GetLoginClient() (*api.Client, string, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, "", err
+	}
+	return client, client.Token(), nil
+}
@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// VaultUnrecoverableError matches Vault error messages that indicate a
+// permanent failure no amount of retrying will fix: the caller lacks
+// permission, the request itself was malformed (including a CSRF
+// rejection), the vault is sealed, or a standby node is redirecting us
+// somewhere else. Anything else (timeouts, connection resets, 5xx) is
+// assumed to be transient, borrowing the approach Nomad's Vault client
+// uses to decide whether a failure is worth retrying.
+var VaultUnrecoverableError = regexp.MustCompile(`(?i)(permission denied|invalid request|csrf|vault is sealed|is sealed|read-only|standby)`)
+
+// RecoverableError wraps an error known (or assumed) to be transient, so
+// callers can retry the operation instead of forcing an approver to
+// refile the whole request.
+type RecoverableError struct {
+	err error
+}
+
+func (e *RecoverableError) Error() string {
+	return e.err.Error()
+}
+
+// Recoverable reports whether err was classified as transient.
+func Recoverable(err error) bool {
+	_, ok := err.(*RecoverableError)
+	return ok
+}
+
+// ClassifyError wraps err as a RecoverableError unless its message
+// matches VaultUnrecoverableError, in which case it is returned as-is so
+// Recoverable(err) reports false.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if VaultUnrecoverableError.MatchString(err.Error()) {
+		return err
+	}
+	return &RecoverableError{err: err}
+}
+
+// maxRetries bounds how many times WithRetry will attempt an operation
+// that keeps failing with a RecoverableError.
+const maxRetries = 3
+
+// WithRetry runs op, classifying any error it returns as recoverable or
+// fatal. A recoverable error (a transient 5xx, a network blip) is retried
+// with jittered exponential backoff, for at most maxRetries attempts in
+// all; a fatal error
+// (permission denied, sealed, standby redirect) is returned immediately.
+// This is meant for the frequently hit, idempotent Vault calls goldfish
+// makes on every request's behalf (cubbyhole reads/writes/deletes, root
+// generation) so a transient blip doesn't force an operator to refile or
+// resubmit.
+func WithRetry(op func() error) error {
+	backoff := 200 * time.Millisecond
+	var err error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		err = ClassifyError(op())
+		if err == nil {
+			return nil
+		}
+		if !Recoverable(err) || attempt == maxRetries-1 {
+			return err
+		}
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+		backoff *= 2
+	}
+	return err
+}
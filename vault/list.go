@@ -0,0 +1,30 @@
+package vault
+
+// ListCubbyhole returns the keys stored directly under path in goldfish's
+// own cubbyhole, non-recursively, e.g. for the Reaper to enumerate every
+// outstanding request hash.
+func ListCubbyhole(path string) ([]string, error) {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+
+	resp, err := client.Logical().List("cubbyhole/" + path)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+
+	raw, ok := resp.Data["keys"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(raw))
+	for _, k := range raw {
+		if s, ok := k.(string); ok {
+			keys = append(keys, s)
+		}
+	}
+	return keys, nil
+}
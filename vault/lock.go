@@ -0,0 +1,163 @@
+package vault
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// ErrKVv2Unavailable is returned by AcquireLock/ReleaseLock when the
+// locks path is not backed by a KV v2 mount (e.g. an older Vault, or an
+// operator who never enabled one), so callers know to fall back to an
+// in-process lock instead of treating it as a real contention failure.
+var ErrKVv2Unavailable = errors.New("KV v2 not available for locking")
+
+const lockMount = "secret"
+const lockPrefix = "goldfish-locks"
+
+// AcquireLock takes a cluster-wide advisory lock on hash using a
+// Check-And-Set write against lockMount's KV v2 data path: the write only
+// succeeds if the entry does not yet exist, its prior holder's TTL has
+// already lapsed, or it is already held by holderID (which renews it and
+// extends its expiry to ttl from now). On success, the lock must
+// eventually be released with ReleaseLock using the same holderID.
+func AcquireLock(hash, holderID string, ttl time.Duration) (bool, error) {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return false, err
+	}
+	client.SetToken(token)
+
+	// checked up front rather than inferred from a Read/Write error code:
+	// the very first acquire on a fresh hash reads back (nil, nil)
+	// regardless of mount version, and a KV v1 write always succeeds
+	// (it ignores cas and just overwrites), so neither call site would
+	// ever see a distinguishing error to infer "not KV v2" from
+	kv2, err := isKVv2(client)
+	if err != nil {
+		return false, err
+	}
+	if !kv2 {
+		return false, ErrKVv2Unavailable
+	}
+
+	path := lockMount + "/data/" + lockPrefix + "/" + hash
+	now := time.Now()
+
+	existing, err := client.Logical().Read(path)
+	if err != nil {
+		if isNotKVv2(err) {
+			return false, ErrKVv2Unavailable
+		}
+		return false, err
+	}
+
+	cas := 0
+	if existing != nil {
+		if meta, ok := existing.Data["metadata"].(map[string]interface{}); ok {
+			if v, ok := meta["version"].(float64); ok {
+				cas = int(v)
+			}
+		}
+		if data, ok := existing.Data["data"].(map[string]interface{}); ok {
+			if expiresRaw, ok := data["expires_at"].(string); ok {
+				expiresAt, err := time.Parse(time.RFC3339, expiresRaw)
+				// a live, unexpired lock held by someone else blocks us
+				if err == nil && now.Before(expiresAt) {
+					if holder, _ := data["holder_id"].(string); holder != holderID {
+						return false, nil
+					}
+				}
+			}
+		}
+	}
+
+	_, err = client.Logical().Write(path, map[string]interface{}{
+		"data": map[string]interface{}{
+			"holder_id":  holderID,
+			"expires_at": now.Add(ttl).Format(time.RFC3339),
+		},
+		"options": map[string]interface{}{
+			"cas": cas,
+		},
+	})
+	if err != nil {
+		if isNotKVv2(err) {
+			return false, ErrKVv2Unavailable
+		}
+		if isCASMismatch(err) {
+			// someone else won the race in between our read and our write
+			return false, nil
+		}
+		// a genuine error (permission denied, sealed, network blip, a
+		// real 5xx) must not be reported back as ordinary contention, or
+		// an operator has no way to tell the two apart
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLock gives up a lock previously acquired by holderID. It is a
+// no-op (not an error) if the lock has already expired or been released.
+func ReleaseLock(hash, holderID string) error {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+
+	path := lockMount + "/data/" + lockPrefix + "/" + hash
+	resp, err := client.Logical().Read(path)
+	if err != nil {
+		if isNotKVv2(err) {
+			return ErrKVv2Unavailable
+		}
+		return err
+	}
+	if resp == nil {
+		return nil
+	}
+	if data, ok := resp.Data["data"].(map[string]interface{}); ok {
+		if holder, _ := data["holder_id"].(string); holder != holderID {
+			// someone else's lock now; nothing for us to release
+			return nil
+		}
+	}
+
+	_, err = client.Logical().Delete(lockMount + "/metadata/" + lockPrefix + "/" + hash)
+	return err
+}
+
+// isKVv2 reports whether lockMount is configured as a KV version 2
+// secrets engine, by checking its mount options directly rather than
+// waiting to see what error shape a Read or CAS Write happens to fail
+// with - against a KV v1 mount, neither call fails at all.
+func isKVv2(client *api.Client) (bool, error) {
+	mounts, err := client.Sys().ListMounts()
+	if err != nil {
+		return false, err
+	}
+	mount, ok := mounts[lockMount+"/"]
+	if !ok {
+		return false, nil
+	}
+	return mount.Options["version"] == "2", nil
+}
+
+func isNotKVv2(err error) bool {
+	if respErr, ok := err.(*api.ResponseError); ok {
+		return respErr.StatusCode == 404
+	}
+	return false
+}
+
+// isCASMismatch reports whether err is Vault's response to a KV v2
+// check-and-set write whose cas no longer matches the stored version,
+// i.e. someone else wrote the path first. Vault reports this as 400.
+func isCASMismatch(err error) bool {
+	if respErr, ok := err.(*api.ResponseError); ok {
+		return respErr.StatusCode == 400
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+// +build integration
+
+package vault
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// TestAcquireLockTwoInstances exercises the scenario AcquireLock exists
+// for: two goldfish replicas racing to acquire the same hash against a
+// real, shared dev Vault with a KV v2 mount at lockMount ("secret/").
+// It needs a live Vault, so it is gated behind the "integration" build
+// tag and an explicit VAULT_ADDR:
+//
+//	VAULT_ADDR=... VAULT_TOKEN=... go test -tags=integration ./vault/... -run TestAcquireLockTwoInstances
+func TestAcquireLockTwoInstances(t *testing.T) {
+	if os.Getenv("VAULT_ADDR") == "" {
+		t.Skip("set VAULT_ADDR (and VAULT_TOKEN) to run against a dev Vault")
+	}
+
+	hash := "integration-test-lock"
+	t.Cleanup(func() {
+		ReleaseLock(hash, "instance-a")
+		ReleaseLock(hash, "instance-b")
+	})
+
+	acquiredA, err := AcquireLock(hash, "instance-a", 5*time.Second)
+	if err != nil {
+		t.Fatalf("instance-a: AcquireLock: %v", err)
+	}
+	if !acquiredA {
+		t.Fatal("instance-a should have won an uncontested lock")
+	}
+
+	acquiredB, err := AcquireLock(hash, "instance-b", 5*time.Second)
+	if err != nil {
+		t.Fatalf("instance-b: AcquireLock: %v", err)
+	}
+	if acquiredB {
+		t.Fatal("instance-b should have lost the race to instance-a's live lock")
+	}
+
+	if err := ReleaseLock(hash, "instance-a"); err != nil {
+		t.Fatalf("instance-a: ReleaseLock: %v", err)
+	}
+
+	acquiredB, err = AcquireLock(hash, "instance-b", 5*time.Second)
+	if err != nil {
+		t.Fatalf("instance-b: AcquireLock after release: %v", err)
+	}
+	if !acquiredB {
+		t.Fatal("instance-b should win the lock once instance-a released it")
+	}
+}
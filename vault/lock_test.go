@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// AcquireLock's distinction between "lost the CAS race" and "a genuine
+// Vault error" hinges entirely on these status code checks, covered
+// here. The actual two-instance race against a shared dev Vault is
+// covered by TestAcquireLockTwoInstances in lock_integration_test.go,
+// which needs a live Vault and so runs behind the "integration" build tag.
+func TestIsCASMismatch(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"cas mismatch", &api.ResponseError{StatusCode: 400}, true},
+		{"not found", &api.ResponseError{StatusCode: 404}, false},
+		{"permission denied", &api.ResponseError{StatusCode: 403}, false},
+		{"server error", &api.ResponseError{StatusCode: 500}, false},
+		{"non-response error", errUnrelated, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isCASMismatch(tc.err); got != tc.want {
+				t.Errorf("isCASMismatch(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsNotKVv2(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"not found", &api.ResponseError{StatusCode: 404}, true},
+		{"cas mismatch", &api.ResponseError{StatusCode: 400}, false},
+		{"non-response error", errUnrelated, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isNotKVv2(tc.err); got != tc.want {
+				t.Errorf("isNotKVv2(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+var errUnrelated = errUnrelatedType{}
+
+type errUnrelatedType struct{}
+
+func (errUnrelatedType) Error() string { return "unrelated error" }
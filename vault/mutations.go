@@ -0,0 +1,84 @@
+package vault
+
+import (
+	"github.com/hashicorp/vault/api"
+)
+
+// TuneMount enables a new secret engine at path (when mountType is
+// non-empty) or tunes an already-mounted engine's configuration, using
+// the provided (short-lived) root token rather than goldfish's own
+// service token.
+func TuneMount(token, path, mountType string, config map[string]interface{}) error {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+
+	if mountType != "" {
+		if err := client.Sys().Mount(path, &api.MountInput{
+			Type: mountType,
+		}); err != nil {
+			return err
+		}
+		if len(config) == 0 {
+			return nil
+		}
+	}
+	_, err = client.Logical().Write("sys/mounts/"+path+"/tune", config)
+	return err
+}
+
+// CreateToken issues a token via the provided (short-lived) root token,
+// honouring the orphan and policies fields of a TokenRequest.
+func CreateToken(token string, req *api.TokenCreateRequest) (*api.Secret, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+
+	if req.NoParent {
+		return client.Auth().Token().CreateOrphan(req)
+	}
+	return client.Auth().Token().Create(req)
+}
+
+// WritePolicy writes an ACL policy using the provided (short-lived) root
+// token, since writing sys/policy requires a root (or sudo) token
+// goldfish does not otherwise hold.
+func WritePolicy(token, name, rules string) error {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+
+	return client.Sys().PutPolicy(name, rules)
+}
+
+// WriteSecret writes data to a KV path using the provided (short-lived)
+// root token, for request types that touch paths goldfish's own service
+// token is not granted access to.
+func WriteSecret(token, path string, data map[string]interface{}) error {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+
+	_, err = client.Logical().Write(path, data)
+	return err
+}
+
+// DeleteFromCubbyhole removes an entry from goldfish's own cubbyhole,
+// used to clean up a request (and its wrapped unseal tokens) once it has
+// been approved, rejected, or has expired.
+func DeleteFromCubbyhole(path string) (*api.Secret, error) {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+	return client.Logical().Delete("cubbyhole/" + path)
+}
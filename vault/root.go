@@ -0,0 +1,77 @@
+package vault
+
+// RootGenerationStatus mirrors the relevant fields of Vault's
+// sys/generate-root/attempt status response.
+type RootGenerationStatus struct {
+	Started  bool
+	Nonce    string
+	Progress int
+	Required int
+
+	// EncodedRootToken is OTP-XORed when GenerateRootInit was called with
+	// an otp, or an ASCII-armored PGP ciphertext when it was called with
+	// a pgpKey instead.
+	EncodedRootToken string
+	// PGPFingerprint is only populated when generation was started with
+	// a pgpKey, and identifies which public key encrypted the token.
+	PGPFingerprint string
+
+	Complete bool
+}
+
+// GenerateRootInit starts a root token generation attempt. Exactly one
+// of otp or pgpKey should be non-empty: otp causes Vault to XOR-encode
+// the token against it, while pgpKey causes Vault to encrypt the token
+// against that ASCII-armored public key instead.
+func GenerateRootInit(otp string, pgpKey string) (*RootGenerationStatus, error) {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+
+	resp, err := client.Sys().GenerateRootInit(otp, pgpKey)
+	if err != nil {
+		return nil, err
+	}
+	return &RootGenerationStatus{
+		Started:          resp.Started,
+		Nonce:            resp.Nonce,
+		Progress:         resp.Progress,
+		Required:         resp.Required,
+		EncodedRootToken: resp.EncodedRootToken,
+		PGPFingerprint:   resp.PGPFingerprint,
+		Complete:         resp.Complete,
+	}, nil
+}
+
+func GenerateRootUpdate(key, nonce string) (*RootGenerationStatus, error) {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(token)
+
+	resp, err := client.Sys().GenerateRootUpdate(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &RootGenerationStatus{
+		Started:          resp.Started,
+		Nonce:            resp.Nonce,
+		Progress:         resp.Progress,
+		Required:         resp.Required,
+		EncodedRootToken: resp.EncodedRootToken,
+		PGPFingerprint:   resp.PGPFingerprint,
+		Complete:         resp.Complete,
+	}, nil
+}
+
+func GenerateRootCancel() error {
+	client, token, err := GetLoginClient()
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+	return client.Sys().GenerateRootCancel()
+}